@@ -0,0 +1,25 @@
+package parse
+
+import "fmt"
+
+// errSource wraps an error encountered while parsing or type-checking
+// a genny source file, so callers can tell a malformed input file
+// apart from any other failure.
+type errSource struct {
+	Err error
+}
+
+func (e *errSource) Error() string {
+	return fmt.Sprintf("parse: source: %v", e.Err)
+}
+
+// errMissingSpecificType reports a generic.Type/generic.Number alias
+// (or, for Generify, a GenericParam) with no corresponding entry in
+// the caller-supplied typeSet/params.
+type errMissingSpecificType struct {
+	GenericType string
+}
+
+func (e *errMissingSpecificType) Error() string {
+	return fmt.Sprintf("parse: missing specific type for %q", e.GenericType)
+}
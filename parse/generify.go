@@ -0,0 +1,502 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"io"
+	"strings"
+)
+
+// GenericParam describes a package-scope `type Name generic.Type` (or
+// generic.Number) alias found in the source, as Generify should emit
+// it: as a Go 1.18+ type parameter named Name.
+type GenericParam struct {
+	Name string
+
+	// Constraint overrides the default constraint Generify would
+	// otherwise infer from the alias's kind: "any" for generic.Type,
+	// or the inline Number interface (see numberConstraintDecl) for
+	// generic.Number.
+	Constraint string
+}
+
+// numberInterfaceName is the name given to the inline constraint
+// interface Generify emits for generic.Number aliases.
+const numberInterfaceName = "Number"
+
+// Generify parses filename and produces a single file using real Go
+// type parameters in place of its `generic.Type`/`generic.Number`
+// aliases, instead of the one-copy-per-typeSet monomorphization
+// Generics performs. Every top-level func, method receiver and type
+// declaration that transitively references one of params gets that
+// param added to its type parameter list (a method's receiver type is
+// instantiated, e.g. `func (l KeyTypeList[KeyType]) Len() int`, since
+// Go doesn't allow a method itself to declare new type parameters).
+// Struct-tag rewriting and identifier capitalization - meaningful only
+// when substituting in a concrete type name - are skipped entirely;
+// the alias name itself becomes the type parameter name. Calls from
+// one generified function to another are instantiated explicitly
+// (e.g. `makeKey[KeyType]()`), since a type parameter that only shows
+// up in a function's result can't always be inferred by the compiler.
+//
+// If params is nil, every generic.Type/generic.Number alias declared
+// in the source is generified with its default constraint. This lets
+// users migrate off genny incrementally: run Generify once, commit
+// the type-parameterized version, delete the generic.Type aliases and
+// any go:generate directives.
+func Generify(filename string, in io.ReadSeeker, params []GenericParam) ([]byte, error) {
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, filename, in, parser.ParseComments)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+
+	aliases := findGenericAliases(file)
+	aliasByName := make(map[string]genericAlias, len(aliases))
+	for _, a := range aliases {
+		aliasByName[a.name] = a
+	}
+
+	if len(params) == 0 {
+		for _, a := range aliases {
+			params = append(params, GenericParam{Name: a.name})
+		}
+	}
+
+	constraints := make(map[string]string, len(params))
+	usesNumber := false
+	for _, p := range params {
+		alias, ok := aliasByName[p.Name]
+		if !ok {
+			return nil, &errMissingSpecificType{GenericType: p.Name}
+		}
+
+		constraint := p.Constraint
+		if constraint == "" {
+			if alias.kind == genericKindNumber {
+				constraint = numberInterfaceName
+				usesNumber = true
+			} else {
+				constraint = "any"
+			}
+		}
+		constraints[p.Name] = constraint
+	}
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: genericImporter{}, Error: func(error) {}}
+	_, _ = conf.Check(file.Name.Name, fs, []*ast.File{file}, info)
+
+	paramObjs := make(map[types.Object]string) // object defining the alias -> param name
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			if _, ok := constraints[ts.Name.Name]; !ok {
+				continue
+			}
+			if obj := info.Defs[ts.Name]; obj != nil {
+				paramObjs[obj] = ts.Name.Name
+			}
+		}
+	}
+
+	reachable := reachableParams(file, info, paramObjs)
+
+	funcObjDecl := make(map[types.Object]*ast.FuncDecl)
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv != nil {
+			continue
+		}
+		if obj := info.Defs[fd.Name]; obj != nil {
+			funcObjDecl[obj] = fd
+		}
+	}
+
+	declTypeParams := make(map[ast.Decl][]string, len(file.Decls))
+	for _, decl := range file.Decls {
+		names := orderedParamNames(reachable[decl], params)
+		if len(names) == 0 {
+			continue
+		}
+		attachTypeParams(decl, names, constraints)
+		declTypeParams[decl] = names
+	}
+
+	// A type parameter that appears only in a function's result -
+	// never in one of its ordinary parameters, as with
+	// `func makeKey[KeyType any]() KeyType` - can't be inferred by the
+	// compiler from a plain call; instantiate every call site
+	// explicitly instead of trying to work out case-by-case whether
+	// inference would have succeeded.
+	instantiateCallSites(file, info, funcObjDecl, declTypeParams)
+
+	removeGenericAliasDecls(file, aliases)
+	removeGenericImport(file)
+
+	if usesNumber {
+		insertNumberConstraintDecl(file)
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fs, file); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// reachableParams computes, for every top-level declaration in file,
+// the set of generic param names (keyed by their defining
+// types.Object, via paramObjs) that decl's body transitively
+// references - either directly, or through another top-level decl it
+// uses that itself references one.
+func reachableParams(file *ast.File, info *types.Info, paramObjs map[types.Object]string) map[ast.Decl]map[string]bool {
+	declOf := make(map[types.Object]ast.Decl)
+	for _, decl := range file.Decls {
+		for _, ident := range declIdents(decl) {
+			if obj := info.Defs[ident]; obj != nil {
+				declOf[obj] = decl
+			}
+		}
+	}
+
+	direct := make(map[ast.Decl]map[string]bool, len(file.Decls))
+	deps := make(map[ast.Decl]map[ast.Decl]bool, len(file.Decls))
+
+	for _, decl := range file.Decls {
+		direct[decl] = make(map[string]bool)
+		deps[decl] = make(map[ast.Decl]bool)
+
+		ast.Inspect(decl, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+
+			obj := info.Uses[ident]
+			if obj == nil {
+				return true
+			}
+
+			if name, ok := paramObjs[obj]; ok {
+				direct[decl][name] = true
+			}
+			if dep, ok := declOf[obj]; ok && dep != decl {
+				deps[decl][dep] = true
+			}
+
+			return true
+		})
+	}
+
+	reachable := make(map[ast.Decl]map[string]bool, len(file.Decls))
+	for decl, names := range direct {
+		reachable[decl] = copyParamSet(names)
+	}
+
+	// Fixed-point iteration: keep propagating a dependency's reachable
+	// set to every decl that depends on it until nothing changes.
+	// Bounded by len(file.Decls) passes, which is always enough to
+	// reach a fixed point regardless of cycles.
+	for i := 0; i < len(file.Decls); i++ {
+		changed := false
+		for decl, declDeps := range deps {
+			for dep := range declDeps {
+				for name := range reachable[dep] {
+					if !reachable[decl][name] {
+						reachable[decl][name] = true
+						changed = true
+					}
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	return reachable
+}
+
+func copyParamSet(s map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(s))
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// declIdents returns the identifiers decl defines at package scope:
+// a FuncDecl's name (but not a method's, since methods aren't looked
+// up by name the same way), and every name in a GenDecl's specs.
+func declIdents(decl ast.Decl) []*ast.Ident {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil {
+			return nil
+		}
+		return []*ast.Ident{d.Name}
+	case *ast.GenDecl:
+		var idents []*ast.Ident
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				idents = append(idents, s.Name)
+			case *ast.ValueSpec:
+				idents = append(idents, s.Names...)
+			}
+		}
+		return idents
+	default:
+		return nil
+	}
+}
+
+// orderedParamNames returns the subset of params (in params' own
+// order) whose name is present in reachable.
+func orderedParamNames(reachable map[string]bool, params []GenericParam) []string {
+	var names []string
+	for _, p := range params {
+		if reachable[p.Name] {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// attachTypeParams adds names (with their constraints) to decl: as a
+// type parameter list on a plain function or type declaration, or as
+// an instantiation of the receiver type for a method, since Go
+// doesn't allow methods to declare new type parameters.
+func attachTypeParams(decl ast.Decl, names []string, constraints map[string]string) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			d.Type.TypeParams = typeParamFieldList(names, constraints)
+			return
+		}
+		if len(d.Recv.List) != 1 {
+			return
+		}
+		recv := d.Recv.List[0]
+		// Replace the whole Field, not just its Type in place, with
+		// entirely fresh (token.NoPos) nodes throughout: go/printer
+		// derives receiver-list line breaks and separators from node
+		// positions, and mixing any of the original receiver's real
+		// positions with new NoPos nodes produced a stray trailing comma
+		// before the closing paren.
+		var freshNames []*ast.Ident
+		for _, n := range recv.Names {
+			freshNames = append(freshNames, ast.NewIdent(n.Name))
+		}
+		d.Recv.List[0] = &ast.Field{
+			Names: freshNames,
+			Type:  instantiatedReceiverType(recv.Type, names),
+		}
+		// Reset the list's own parens too - their original positions no
+		// longer bracket a field whose own nodes carry real positions,
+		// which is what was confusing go/printer.
+		d.Recv.Opening = token.NoPos
+		d.Recv.Closing = token.NoPos
+
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			ts.TypeParams = typeParamFieldList(names, constraints)
+		}
+	}
+}
+
+// typeParamFieldList builds the `[A any, B Number]`-style field list
+// for a type parameter declaration, one field per name so each can
+// carry its own constraint.
+func typeParamFieldList(names []string, constraints map[string]string) *ast.FieldList {
+	fields := make([]*ast.Field, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  ast.NewIdent(constraints[name]),
+		})
+	}
+	return &ast.FieldList{List: fields}
+}
+
+// instantiatedReceiverType rewrites a method receiver type (Foo, or
+// *Foo) to instantiate it with names (Foo[A, B], or *Foo[A, B]). It
+// builds entirely fresh, unpositioned (token.NoPos) nodes rather than
+// reusing any part of recvType - go/printer derives its line-break and
+// separator decisions from node positions, and splicing a NoPos node
+// in alongside the original receiver's real positions confused it into
+// emitting a stray trailing comma before the closing paren.
+func instantiatedReceiverType(recvType ast.Expr, names []string) ast.Expr {
+	star, isPointer := recvType.(*ast.StarExpr)
+	base := recvType
+	if isPointer {
+		base = star.X
+	}
+	base = ast.NewIdent(receiverTypeName(base))
+
+	indices := make([]ast.Expr, 0, len(names))
+	for _, name := range names {
+		indices = append(indices, ast.NewIdent(name))
+	}
+
+	var instantiated ast.Expr
+	if len(indices) == 1 {
+		instantiated = &ast.IndexExpr{X: base, Index: indices[0]}
+	} else {
+		instantiated = &ast.IndexListExpr{X: base, Indices: indices}
+	}
+
+	if isPointer {
+		return &ast.StarExpr{X: instantiated}
+	}
+	return instantiated
+}
+
+// receiverTypeName returns the plain type name from a (non-pointer,
+// non-instantiated) receiver base expression, which is always an
+// *ast.Ident for a genny source file's receivers.
+func receiverTypeName(base ast.Expr) string {
+	if ident, ok := base.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// instantiateCallSites rewrites every call to a package-scope function
+// (funcObjDecl) that itself ended up with type parameters attached
+// (declTypeParams) into an explicit instantiation, e.g. makeKey() ->
+// makeKey[KeyType](). The calling decl is always reachable for the
+// same names by construction (reachableParams propagates a callee's
+// params to every caller), so the names are already in scope there.
+func instantiateCallSites(file *ast.File, info *types.Info, funcObjDecl map[types.Object]*ast.FuncDecl, declTypeParams map[ast.Decl][]string) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := call.Fun.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		fd, ok := funcObjDecl[info.Uses[ident]]
+		if !ok {
+			return true
+		}
+		names := declTypeParams[fd]
+		if len(names) == 0 {
+			return true
+		}
+
+		args := make([]ast.Expr, 0, len(names))
+		for _, name := range names {
+			args = append(args, ast.NewIdent(name))
+		}
+		if len(args) == 1 {
+			call.Fun = &ast.IndexExpr{X: ast.NewIdent(ident.Name), Index: args[0]}
+		} else {
+			call.Fun = &ast.IndexListExpr{X: ast.NewIdent(ident.Name), Indices: args}
+		}
+		return true
+	})
+}
+
+// removeGenericImport drops the `import "generic"` declaration from
+// file: every generic.Type/generic.Number alias is always fully
+// removed by Generify, so by the time this runs the package is
+// guaranteed to be unused.
+func removeGenericImport(file *ast.File) {
+	var decls []ast.Decl
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+
+		var specs []ast.Spec
+		for _, spec := range gd.Specs {
+			is, ok := spec.(*ast.ImportSpec)
+			if ok && strings.Trim(is.Path.Value, `"`) == genericPackage {
+				continue
+			}
+			specs = append(specs, spec)
+		}
+		if len(specs) == 0 {
+			continue
+		}
+		gd.Specs = specs
+		decls = append(decls, gd)
+	}
+	file.Decls = decls
+}
+
+// insertNumberConstraintDecl inserts the Number constraint interface
+// Generify's generic.Number aliases map to, right after the package
+// clause.
+func insertNumberConstraintDecl(file *ast.File) {
+	numberType := &ast.InterfaceType{
+		Methods: &ast.FieldList{
+			List: []*ast.Field{
+				{Type: numberUnionType()},
+			},
+		},
+	}
+
+	decl := &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: ast.NewIdent(numberInterfaceName),
+				Type: numberType,
+			},
+		},
+	}
+
+	file.Decls = append([]ast.Decl{decl}, file.Decls...)
+}
+
+// numberUnionType builds the `~int | ~int8 | ... | ~float64` term
+// union embedded in the Number constraint interface.
+func numberUnionType() ast.Expr {
+	kinds := []string{
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"float32", "float64",
+	}
+
+	var union ast.Expr
+	for _, k := range kinds {
+		term := &ast.UnaryExpr{Op: token.TILDE, X: ast.NewIdent(k)}
+		if union == nil {
+			union = term
+			continue
+		}
+		union = &ast.BinaryExpr{X: union, Op: token.OR, Y: term}
+	}
+
+	return union
+}
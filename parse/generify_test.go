@@ -0,0 +1,173 @@
+package parse
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requireCompiles writes src as its own module under a temp directory
+// and shells out to `go build` on it, failing the test if the
+// generated file doesn't actually compile. Substring assertions on
+// Generify's output can't catch a signature that's syntactically
+// plausible but semantically broken (e.g. a type parameter the
+// compiler can't infer at a call site); this is the only thing that
+// can. Skips if no go toolchain is on PATH.
+func requireCompiles(t *testing.T, filename string, src []byte) {
+	t.Helper()
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go toolchain on PATH")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module generifycheck\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filename), src, 0644); err != nil {
+		t.Fatalf("write %s: %v", filename, err)
+	}
+
+	cmd := exec.Command(goBin, "build", "./...")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated output does not compile: %v\n%s\nsource:\n%s", err, out, src)
+	}
+}
+
+func TestGenerify(t *testing.T) {
+	src := `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+type KeyTypeList struct {
+	items []KeyType
+}
+
+func (l *KeyTypeList) Add(k KeyType) {
+	l.items = append(l.items, k)
+}
+
+func helper() int {
+	return 0
+}
+`
+
+	out, err := Generify("list.go", strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatalf("Generify: %v", err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		"type KeyTypeList[KeyType any] struct",
+		"func (l *KeyTypeList[KeyType]) Add(k KeyType)",
+		"func helper() int",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q\ngot:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "generic.Type") {
+		t.Errorf("generic.Type alias declaration should have been removed\ngot:\n%s", got)
+	}
+
+	requireCompiles(t, "list.go", out)
+}
+
+func TestGenerifyNumberConstraint(t *testing.T) {
+	src := `package example
+
+import "generic"
+
+type NumberType generic.Number
+
+func Sum(vs []NumberType) NumberType {
+	var total NumberType
+	for _, v := range vs {
+		total += v
+	}
+	return total
+}
+`
+
+	out, err := Generify("sum.go", strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatalf("Generify: %v", err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		"type Number interface",
+		"~int",
+		"~float64",
+		"func Sum[NumberType Number](vs []NumberType) NumberType",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q\ngot:\n%s", want, got)
+		}
+	}
+
+	requireCompiles(t, "sum.go", out)
+}
+
+func TestGenerifyTransitiveReachability(t *testing.T) {
+	src := `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+func makeKey() KeyType {
+	var z KeyType
+	return z
+}
+
+func printKey() {
+	_ = makeKey()
+}
+`
+
+	out, err := Generify("key.go", strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatalf("Generify: %v", err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		"func makeKey[KeyType any]() KeyType",
+		"func printKey[KeyType any]()",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q\ngot:\n%s", want, got)
+		}
+	}
+
+	// makeKey's KeyType only appears in its result, never one of its
+	// ordinary parameters, so the compiler can't infer it at a plain
+	// call site - printKey's call must be instantiated explicitly.
+	if !strings.Contains(got, "makeKey[KeyType]()") {
+		t.Errorf("output missing explicit call-site instantiation %q\ngot:\n%s", "makeKey[KeyType]()", got)
+	}
+
+	requireCompiles(t, "key.go", out)
+}
+
+func TestGenerifyUnknownParam(t *testing.T) {
+	src := `package example
+
+import "generic"
+
+type KeyType generic.Type
+`
+
+	_, err := Generify("key.go", strings.NewReader(src), []GenericParam{{Name: "NotDeclared"}})
+	if err == nil {
+		t.Fatalf("expected an error for a param with no matching alias")
+	}
+}
@@ -0,0 +1,213 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+)
+
+// importSpec is a single import as declared in the original source
+// file: its local name (empty for the default package name, "_" for a
+// blank import, "." for a dot import) and its import path.
+type importSpec struct {
+	name string
+	path string
+}
+
+// importSet accumulates the imports a generated file needs: every
+// import already present in the original source, plus the package
+// paths pulled in by whichever specific types were substituted for
+// each generic alias across every typeSet. Generics renders it once
+// into a single merged `import (...)` block instead of relying on
+// imports.Process to rediscover everything from scratch.
+type importSet struct {
+	original    []importSpec          // every import declared in the original source, in order
+	byShorthand map[string]importSpec // local name -> import, for named/default imports only
+	specific    map[string]string     // import path -> local name ("" for default)
+	shorthands  map[string]string     // package shorthand -> full import path
+}
+
+// newImportSet creates an importSet that resolves package shorthands
+// it can't find in the original file's imports against shorthands
+// (e.g. "uuid" -> "github.com/google/uuid"), as supplied by the
+// caller of Generics.
+func newImportSet(shorthands map[string]string) *importSet {
+	return &importSet{
+		byShorthand: make(map[string]importSpec),
+		specific:    make(map[string]string),
+		shorthands:  shorthands,
+	}
+}
+
+// recordOriginal records every import declared in file's import
+// declarations. Named and default imports are also indexed by the
+// local identifier used to refer to the package elsewhere in the
+// file, for recordSpecificType's lookups; blank ("_") and dot (".")
+// imports have no such identifier (and collide with one another if
+// keyed by name, since every blank import shares the literal name
+// "_"), so they're kept in original only and always passed through
+// by path.
+func (s *importSet) recordOriginal(file *ast.File) {
+	for _, imp := range file.Imports {
+		path := strings.Trim(imp.Path.Value, `"`)
+
+		name := ""
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+
+		spec := importSpec{name: name, path: path}
+		s.original = append(s.original, spec)
+
+		if name == "_" || name == "." {
+			continue
+		}
+
+		localName := name
+		if localName == "" {
+			localName = path[strings.LastIndex(path, "/")+1:]
+		}
+		s.byShorthand[localName] = spec
+	}
+}
+
+// recordSpecificType inspects a specific type string such as "int",
+// "time.Time", "*uuid.UUID" or "[]pkg.Thing" for a leading package
+// shorthand. If the shorthand matches a package already imported by
+// the original file, or one supplied in the caller's shorthand->path
+// map, its import is added to the set; types with no package
+// qualifier (e.g. "int") are ignored.
+func (s *importSet) recordSpecificType(specificType string) {
+	shorthand, ok := packageShorthand(specificType)
+	if !ok {
+		return
+	}
+
+	if spec, ok := s.byShorthand[shorthand]; ok {
+		s.specific[spec.path] = spec.name
+		return
+	}
+
+	if path, ok := s.shorthands[shorthand]; ok {
+		s.specific[path] = ""
+	}
+}
+
+// packageShorthand extracts the leading package identifier from a
+// specific type expression, e.g. "*pkg.Type" -> "pkg", "[]pkg.Type" ->
+// "pkg", "uuid.UUID" -> "uuid". It returns ok=false for types with no
+// package qualifier.
+func packageShorthand(specificType string) (string, bool) {
+	t := strings.TrimLeft(specificType, "*[]")
+
+	dot := strings.Index(t, ".")
+	if dot <= 0 {
+		return "", false
+	}
+
+	return t[:dot], true
+}
+
+// render emits a single merged, deduplicated `import (...)` block
+// combining every import recorded by recordOriginal and
+// recordSpecificType, or nil if there's nothing to import.
+func (s *importSet) render() []byte {
+	type entry struct {
+		name, path string
+	}
+
+	seen := make(map[string]bool)
+	var entries []entry
+
+	addEntry := func(name, path string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		entries = append(entries, entry{name: name, path: path})
+	}
+
+	for _, spec := range s.original {
+		addEntry(spec.name, spec.path)
+	}
+	for path, name := range s.specific {
+		addEntry(name, path)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+
+	var buf bytes.Buffer
+	buf.WriteString("import (\n")
+	for _, e := range entries {
+		if e.name != "" {
+			fmt.Fprintf(&buf, "\t%s %q\n", e.name, e.path)
+		} else {
+			fmt.Fprintf(&buf, "\t%q\n", e.path)
+		}
+	}
+	buf.WriteString(")\n")
+
+	return buf.Bytes()
+}
+
+// mergeImports collapses totalOutput - the concatenation of one or
+// more generated copies, each still carrying its own package clause
+// and import block - down to a single package clause followed by
+// set's merged import block, dropping every per-copy import block and
+// any line matching unwantedLinePrefixes along the way. Both Generics
+// and Template call this, so the two front-ends share one
+// import-handling implementation instead of quietly diverging on it.
+func mergeImports(totalOutput []byte, set *importSet) []byte {
+	packageFound := false
+	insideImportBlock := false
+	var cleanOutputLines []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(totalOutput))
+	for scanner.Scan() {
+		if insideImportBlock {
+			if bytes.HasSuffix(scanner.Bytes(), closeBrace) {
+				insideImportBlock = false
+			}
+			continue
+		}
+
+		if bytes.HasPrefix(scanner.Bytes(), packageKeyword) {
+			if packageFound {
+				continue
+			}
+			packageFound = true
+			cleanOutputLines = append(cleanOutputLines, makeLine(scanner.Text()))
+			if block := set.render(); block != nil {
+				cleanOutputLines = append(cleanOutputLines, string(block))
+			}
+			continue
+		} else if bytes.HasPrefix(scanner.Bytes(), importKeyword) {
+			if bytes.HasSuffix(scanner.Bytes(), openBrace) {
+				insideImportBlock = true
+			}
+			continue
+		}
+
+		skipline := false
+		for _, prefix := range unwantedLinePrefixes {
+			if bytes.HasPrefix(scanner.Bytes(), prefix) {
+				skipline = true
+				continue
+			}
+		}
+		if skipline {
+			continue
+		}
+
+		cleanOutputLines = append(cleanOutputLines, makeLine(scanner.Text()))
+	}
+
+	return []byte(strings.Join(cleanOutputLines, ""))
+}
@@ -0,0 +1,122 @@
+package parse
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportSetRecordOriginal(t *testing.T) {
+	src := `package example
+
+import (
+	"fmt"
+	renamed "strings"
+	_ "net/http/pprof"
+	_ "github.com/lib/pq"
+)
+`
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, "test.go", src, parser.ImportsOnly)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+
+	set := newImportSet(nil)
+	set.recordOriginal(file)
+
+	if spec, ok := set.byShorthand["fmt"]; !ok || spec.path != "fmt" {
+		t.Errorf("expected fmt to be recorded, got %+v, %v", spec, ok)
+	}
+	if spec, ok := set.byShorthand["renamed"]; !ok || spec.path != "strings" || spec.name != "renamed" {
+		t.Errorf("expected renamed import of strings, got %+v, %v", spec, ok)
+	}
+
+	// blank imports all share the literal local name "_" - keying by
+	// name alone would make the second overwrite the first
+	var blankPaths []string
+	for _, spec := range set.original {
+		if spec.name == "_" {
+			blankPaths = append(blankPaths, spec.path)
+		}
+	}
+	wantBlank := []string{"net/http/pprof", "github.com/lib/pq"}
+	if !reflect.DeepEqual(blankPaths, wantBlank) {
+		t.Errorf("expected both blank imports to be recorded, got %v, want %v", blankPaths, wantBlank)
+	}
+}
+
+func TestImportSetRecordSpecificType(t *testing.T) {
+	set := newImportSet(map[string]string{"uuid": "github.com/google/uuid"})
+	set.recordOriginal(mustParseImports(t, `package example
+
+import "time"
+`))
+
+	set.recordSpecificType("int")
+	set.recordSpecificType("time.Time")
+	set.recordSpecificType("*uuid.UUID")
+	set.recordSpecificType("[]unknownpkg.Thing")
+
+	if _, ok := set.specific["time"]; !ok {
+		t.Errorf("expected time to be pulled in from the original imports")
+	}
+	if _, ok := set.specific["github.com/google/uuid"]; !ok {
+		t.Errorf("expected uuid shorthand to resolve via the supplied map")
+	}
+	if _, ok := set.specific["unknownpkg"]; ok {
+		t.Errorf("unresolvable shorthand should not produce an import")
+	}
+}
+
+func TestImportSetRender(t *testing.T) {
+	set := newImportSet(map[string]string{"uuid": "github.com/google/uuid"})
+	set.recordOriginal(mustParseImports(t, `package example
+
+import "fmt"
+`))
+	set.recordSpecificType("*uuid.UUID")
+
+	got := string(set.render())
+	for _, want := range []string{"import (", `"fmt"`, `"github.com/google/uuid"`, ")"} {
+		if !contains(got, want) {
+			t.Errorf("render() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestImportSetRenderKeepsEveryBlankImport(t *testing.T) {
+	set := newImportSet(nil)
+	set.recordOriginal(mustParseImports(t, `package example
+
+import (
+	_ "net/http/pprof"
+	_ "github.com/lib/pq"
+)
+`))
+
+	got := string(set.render())
+	for _, want := range []string{`_ "net/http/pprof"`, `_ "github.com/lib/pq"`} {
+		if !contains(got, want) {
+			t.Errorf("render() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func mustParseImports(t *testing.T, src string) *ast.File {
+	t.Helper()
+
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, "test.go", src, parser.ImportsOnly)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	return file
+}
+
+func contains(s, substr string) bool {
+	return strings.Contains(s, substr)
+}
@@ -4,15 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"go/ast"
 	"go/parser"
-	"go/scanner"
 	"go/token"
 	"io"
 	"strings"
 	"unicode"
 
-	"github.com/iancoleman/strcase"
 	"golang.org/x/tools/imports"
 )
 
@@ -30,8 +27,6 @@ var (
 	openBrace      = []byte("(")
 	closeBrace     = []byte(")")
 	genericPackage = "generic"
-	genericType    = "generic.Type"
-	genericNumber  = "generic.Number"
 	linefeed       = "\r\n"
 )
 var unwantedLinePrefixes = [][]byte{
@@ -64,63 +59,32 @@ func subIntoLiteral(lit, typeTemplate, specificType, specificName string) string
 	return result
 }
 
-func subIntoStructTag(lit string, typeTemplate string, specificType string, specificName string) string {
-	capitalizedName := wordify(specificType, specificName, true)
-
-	snakeCaseName, ok := cacheSnakeCaseNames[capitalizedName]
-	if !ok {
-		snakeCaseName = strcase.ToSnake(capitalizedName)
-		cacheSnakeCaseNames[capitalizedName] = snakeCaseName
+// subTypeIntoComment substitutes typeTemplate for specificType/specificName
+// in each word of a comment. A block comment's Text spans multiple
+// lines in one string, so the substitution is done line-by-line and
+// rejoined with "\n" - word-splitting the whole text at once would
+// flatten a multi-line /* ... */ comment onto a single line.
+func subTypeIntoComment(text, typeTemplate, specificType, specificName string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = subTypeIntoCommentLine(line, typeTemplate, specificType, specificName)
 	}
 
-	result := strings.Replace(lit, typeTemplate, snakeCaseName, -1)
-
-	return result
-}
-
-func isStructTag(lit string) bool {
-	return strings.HasPrefix(lit, "`db:") || strings.HasPrefix(lit, "`json:")
+	return strings.Join(lines, "\n")
 }
 
-func subTypeIntoComment(line, typeTemplate, specificType, specificName string) string {
+func subTypeIntoCommentLine(line, typeTemplate, specificType, specificName string) string {
 	var sb strings.Builder
 
-	var subbed string
-	for _, w := range strings.Fields(line) {
-		sb.WriteString(subIntoLiteral(w, typeTemplate, specificType, specificName))
-		sb.WriteString(" ")
-	}
-	return subbed
-}
-
-// Does the heavy lifting of taking a line of our code and
-// substituting a type into there for our generic type
-func subTypeIntoLine(line, typeTemplate, specificType, specificName string) string {
-	src := []byte(line)
-	var s scanner.Scanner
-	fset := token.NewFileSet()
-	file := fset.AddFile("", fset.Base(), len(src))
-	s.Init(file, src, nil, scanner.ScanComments)
-
-	var output strings.Builder
-
-	for {
-		_, tok, lit := s.Scan()
-		if tok == token.EOF {
-			break
-		} else if tok == token.COMMENT {
-			subbed := subTypeIntoComment(lit, typeTemplate, specificType, specificName)
-			output.WriteString(subbed)
-		} else if tok.IsLiteral() {
-			subbed := subIntoLiteral(lit, typeTemplate, specificType, specificName)
-			output.WriteString(subbed)
-		} else {
-			output.WriteString(tok.String())
+	words := strings.Fields(line)
+	for i, w := range words {
+		if i > 0 {
+			sb.WriteString(" ")
 		}
-		output.WriteString(" ")
+		sb.WriteString(subIntoLiteral(w, typeTemplate, specificType, specificName))
 	}
 
-	return output.String()
+	return sb.String()
 }
 
 // typeSet looks like "KeyType: int, ValueType: string"
@@ -132,95 +96,43 @@ func generateSpecific(filename string, in io.ReadSeeker, typeSet map[string]stri
 		return nil, err
 	}
 
-	// parse the source file
+	// parse the source file, comments and all, so the rewrite pass
+	// below can resolve identifiers with go/types and re-emit
+	// everything (including comments and struct tags) with go/printer
 	fs := token.NewFileSet()
-	file, err := parser.ParseFile(fs, filename, in, 0)
+	file, err := parser.ParseFile(fs, filename, in, parser.ParseComments)
 	if err != nil {
 		return nil, &errSource{Err: err}
 	}
 
-	// make sure every generic.Type is represented in the types
-	// argument.
-	for _, decl := range file.Decls {
-		switch it := decl.(type) {
-		case *ast.GenDecl:
-			for _, spec := range it.Specs {
-				ts, ok := spec.(*ast.TypeSpec)
-				if !ok {
-					continue
-				}
-				switch tt := ts.Type.(type) {
-				case *ast.SelectorExpr:
-					if name, identOK := tt.X.(*ast.Ident); identOK {
-						if name.Name == genericPackage {
-							if _, typesetContains := typeSet[ts.Name.Name]; !typesetContains {
-								return nil, &errMissingSpecificType{GenericType: ts.Name.Name}
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	_, err = in.Seek(0, io.SeekStart)
-	if err != nil {
-		return nil, err
-	}
-
-	var buf bytes.Buffer
-
-	comment := ""
-	scanner := bufio.NewScanner(in)
-	for scanner.Scan() {
-
-		line := scanner.Text()
-
-		// does this line contain generic.Type?
-		if strings.Contains(line, genericType) || strings.Contains(line, genericNumber) {
-			comment = ""
-			continue
-		}
-
-		for t, specificType := range typeSet {
-			var specificName string
-
-			if strings.Contains(specificType, ":") {
-				split := strings.Split(specificType, ":")
-				specificType = split[0]
-				specificName = split[1]
-			}
-
-			if strings.Contains(line, t) {
-				newLine := subTypeIntoLine(line, t, specificType, specificName)
-				line = newLine
-			}
-		}
-
-		if comment != "" {
-			buf.WriteString(makeLine(comment))
-			comment = ""
+	// make sure every generic.Type/generic.Number alias is represented
+	// in the typeSet argument
+	for _, alias := range findGenericAliases(file) {
+		if _, ok := typeSet[alias.name]; !ok {
+			return nil, &errMissingSpecificType{GenericType: alias.name}
 		}
-
-		// is this line a comment?
-		// TODO: should we handle /* */ comments?
-		if strings.HasPrefix(line, "//") {
-			// record this line to print later
-			comment = line
-			continue
-		}
-
-		// write the line
-		buf.WriteString(makeLine(line))
 	}
 
-	// write it out
-	return buf.Bytes(), nil
+	return rewriteTypeSet(fs, file, typeSet)
 }
 
 // Generics parses the source file and generates the bytes replacing the
 // generic types for the keys map with the specific types (its value).
-func Generics(filename, outputFilename, pkgName string, in io.ReadSeeker, typeSets []map[string]string) ([]byte, error) {
+//
+// importPaths optionally supplies a package-shorthand->import-path map
+// (e.g. {"uuid": "github.com/google/uuid"}) used to resolve specific
+// types that reference a package the original source doesn't already
+// import. Pass nothing if every specific type is either unqualified
+// (e.g. "int") or qualified by a package the source already imports.
+func Generics(filename, outputFilename, pkgName string, in io.ReadSeeker, typeSets []map[string]string, importPaths ...map[string]string) ([]byte, error) {
+
+	var shorthands map[string]string
+	if len(importPaths) > 0 {
+		shorthands = importPaths[0]
+	}
+
+	importSetForOutput := newImportSet(shorthands)
+	recordImportsFor(importSetForOutput, filename, in, typeSets)
 
 	totalOutput := header
 
@@ -236,69 +148,48 @@ func Generics(filename, outputFilename, pkgName string, in io.ReadSeeker, typeSe
 
 	}
 
-	// clean up the code line by line
-	packageFound := false
-	insideImportBlock := false
-	var cleanOutputLines []string
-	scanner := bufio.NewScanner(bytes.NewReader(totalOutput))
-	for scanner.Scan() {
-
-		// end of imports block?
-		if insideImportBlock {
-			if bytes.HasSuffix(scanner.Bytes(), closeBrace) {
-				insideImportBlock = false
-			}
-			continue
-		}
-
-		if bytes.HasPrefix(scanner.Bytes(), packageKeyword) {
-			if packageFound {
-				continue
-			} else {
-				packageFound = true
-			}
-		} else if bytes.HasPrefix(scanner.Bytes(), importKeyword) {
-			if bytes.HasSuffix(scanner.Bytes(), openBrace) {
-				insideImportBlock = true
-			}
-			continue
-		}
-
-		// check all unwantedLinePrefixes - and skip them
-		skipline := false
-		for _, prefix := range unwantedLinePrefixes {
-			if bytes.HasPrefix(scanner.Bytes(), prefix) {
-				skipline = true
-				continue
-			}
-		}
-
-		if skipline {
-			continue
-		}
-
-		cleanOutputLines = append(cleanOutputLines, makeLine(scanner.Text()))
-	}
-
-	cleanOutput := strings.Join(cleanOutputLines, "")
-
-	output := []byte(cleanOutput)
-	var err error
+	// collapse every per-instantiation package clause and import block
+	// down to a single package clause followed by the one merged
+	// import block computed above, dropping unwanted line prefixes
+	output := mergeImports(totalOutput, importSetForOutput)
 
 	// change package name
 	if pkgName != "" {
 		output = changePackage(bytes.NewReader([]byte(output)), pkgName)
 	}
 
-	// fix the imports
-	output, err = imports.Process(outputFilename, output, nil)
-	if err != nil {
-		return nil, &errImports{Err: err}
+	// imports.Process is now just a best-effort gofmt/goimports pass for
+	// cosmetics (e.g. grouping, alignment) - correctness no longer
+	// depends on it, so a failure here (e.g. no GOPATH/module available)
+	// isn't fatal.
+	if formatted, err := imports.Process(outputFilename, output, nil); err == nil {
+		output = formatted
 	}
 
 	return output, nil
 }
 
+// recordImportsFor records imports into set: every import declared in
+// the original source file, plus the package paths referenced by
+// every specific type across every typeSet.
+func recordImportsFor(set *importSet, filename string, in io.ReadSeeker, typeSets []map[string]string) {
+	if _, err := in.Seek(0, io.SeekStart); err == nil {
+		fs := token.NewFileSet()
+		if file, err := parser.ParseFile(fs, filename, in, parser.ImportsOnly); err == nil {
+			set.recordOriginal(file)
+		}
+	}
+
+	for _, typeSet := range typeSets {
+		for _, specificType := range typeSet {
+			if strings.Contains(specificType, ":") {
+				specificType = strings.SplitN(specificType, ":", 2)[0]
+			}
+			set.recordSpecificType(specificType)
+		}
+	}
+}
+
 func makeLine(s string) string {
 	return fmt.Sprintln(strings.TrimRight(s, linefeed))
 }
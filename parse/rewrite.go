@@ -0,0 +1,238 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// genericKind distinguishes a `generic.Type` alias from a
+// `generic.Number` alias. Plain substitution treats them the same;
+// generifyFile (see generify.go) cares about the distinction.
+type genericKind int
+
+const (
+	genericKindType genericKind = iota
+	genericKindNumber
+)
+
+// genericAlias is a package-scope `type X generic.Type` (or
+// `generic.Number`) declaration found in a source file.
+type genericAlias struct {
+	name string
+	kind genericKind
+}
+
+// findGenericAliases returns every package-scope generic alias
+// declared in file, in declaration order.
+func findGenericAliases(file *ast.File) []genericAlias {
+	var aliases []genericAlias
+
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+
+			sel, ok := ts.Type.(*ast.SelectorExpr)
+			if !ok {
+				continue
+			}
+
+			pkg, ok := sel.X.(*ast.Ident)
+			if !ok || pkg.Name != genericPackage {
+				continue
+			}
+
+			switch sel.Sel.Name {
+			case "Type":
+				aliases = append(aliases, genericAlias{name: ts.Name.Name, kind: genericKindType})
+			case "Number":
+				aliases = append(aliases, genericAlias{name: ts.Name.Name, kind: genericKindNumber})
+			}
+		}
+	}
+
+	return aliases
+}
+
+// genericImporter resolves the "generic" import path to a stub
+// package containing just enough (Type and Number, both defined as
+// int) for go/types to type-check a genny source file without the
+// real github.com/cheekybits/genny/generic package being on disk.
+type genericImporter struct{}
+
+func (genericImporter) Import(path string) (*types.Package, error) {
+	if path != genericPackage && !strings.HasSuffix(path, "/"+genericPackage) {
+		return nil, fmt.Errorf("parse: unresolved import %q", path)
+	}
+
+	pkg := types.NewPackage(path, genericPackage)
+	scope := pkg.Scope()
+	scope.Insert(types.NewTypeName(token.NoPos, pkg, "Type", types.Typ[types.Int]))
+	scope.Insert(types.NewTypeName(token.NoPos, pkg, "Number", types.Typ[types.Int]))
+	pkg.MarkComplete()
+
+	return pkg, nil
+}
+
+// specificNameFor splits a typeSet value of the form "int" or
+// "int:Age" into the specific type and an optional override for the
+// generated identifier name.
+func specificNameFor(typeSet map[string]string, genericName string) (specificType, specificName string) {
+	raw, ok := typeSet[genericName]
+	if !ok {
+		return "", ""
+	}
+
+	if strings.Contains(raw, ":") {
+		split := strings.SplitN(raw, ":", 2)
+		return split[0], split[1]
+	}
+
+	return raw, ""
+}
+
+// rewriteTypeSet substitutes every identifier in file that resolves
+// (via go/types) to one of its package-scope generic aliases with the
+// specific type from typeSet, rewrites matching struct tags, string
+// literals and comments the same way, drops the alias declarations
+// themselves, and prints the result with go/printer.
+//
+// Unlike the old line-by-line heuristic, a literal or identifier is
+// only ever rewritten because it genuinely refers to the generic
+// alias - an unrelated identifier that merely contains the alias name
+// as a substring is left untouched.
+func rewriteTypeSet(fset *token.FileSet, file *ast.File, typeSet map[string]string) ([]byte, error) {
+	aliases := findGenericAliases(file)
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: genericImporter{}, Error: func(error) {}}
+	// Best-effort: a standalone genny source file often doesn't type-check
+	// in isolation (unresolved packages, missing siblings), so errors here
+	// are expected and ignored - info.Defs/Uses are still populated for
+	// everything the checker could resolve before giving up.
+	_, _ = conf.Check(file.Name.Name, fset, []*ast.File{file}, info)
+
+	targets := make(map[types.Object]genericAlias)
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+
+			for _, alias := range aliases {
+				if alias.name != ts.Name.Name {
+					continue
+				}
+				if obj := info.Defs[ts.Name]; obj != nil {
+					targets[obj] = alias
+				}
+			}
+		}
+	}
+
+	// Drop the `type X generic.Type`/`generic.Number` declarations
+	// themselves before rewriting identifiers below - otherwise the
+	// ast.Inspect pass renames ts.Name right along with every other
+	// reference (info.Defs[ts.Name] is exactly one of our targets), and
+	// by the time this ran after the rewrite the alias decl's name no
+	// longer matched anything removeGenericAliasDecls was looking for.
+	removeGenericAliasDecls(file, aliases)
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.Ident:
+			obj := info.Uses[node]
+			if obj == nil {
+				obj = info.Defs[node]
+			}
+			alias, ok := targets[obj]
+			if !ok {
+				return true
+			}
+			specificType, specificName := specificNameFor(typeSet, alias.name)
+			node.Name = subIntoLiteral(node.Name, alias.name, specificType, specificName)
+
+		case *ast.BasicLit:
+			if node.Kind != token.STRING {
+				return true
+			}
+			for _, alias := range aliases {
+				if !strings.Contains(node.Value, alias.name) {
+					continue
+				}
+				specificType, specificName := specificNameFor(typeSet, alias.name)
+				if isStructTag(node.Value) {
+					node.Value = subIntoStructTag(node.Value, alias.name, specificType, specificName)
+				} else {
+					node.Value = subIntoLiteral(node.Value, alias.name, specificType, specificName)
+				}
+			}
+		}
+		return true
+	})
+
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			for _, alias := range aliases {
+				if !strings.Contains(c.Text, alias.name) {
+					continue
+				}
+				specificType, specificName := specificNameFor(typeSet, alias.name)
+				c.Text = subTypeIntoComment(c.Text, alias.name, specificType, specificName)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, file); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// removeGenericAliasDecls strips the `type X generic.Type` (and
+// generic.Number) declarations from file now that every reference to
+// them has been substituted.
+func removeGenericAliasDecls(file *ast.File, aliases []genericAlias) {
+	isAlias := make(map[string]bool, len(aliases))
+	for _, a := range aliases {
+		isAlias[a.name] = true
+	}
+
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE || len(gd.Specs) != 1 {
+			decls = append(decls, decl)
+			continue
+		}
+
+		ts, ok := gd.Specs[0].(*ast.TypeSpec)
+		if !ok || !isAlias[ts.Name.Name] {
+			decls = append(decls, decl)
+		}
+	}
+	file.Decls = decls
+}
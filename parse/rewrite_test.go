@@ -0,0 +1,181 @@
+package parse
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// rewriteSource is a small helper that parses src as a standalone Go
+// file and runs it through rewriteTypeSet with typeSet, returning the
+// generated source as a string for substring assertions.
+func rewriteSource(t *testing.T, src string, typeSet map[string]string) string {
+	t.Helper()
+
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+
+	out, err := rewriteTypeSet(fs, file, typeSet)
+	if err != nil {
+		t.Fatalf("rewriteTypeSet: %v", err)
+	}
+
+	return string(out)
+}
+
+func TestRewriteTypeSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		typeSet map[string]string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "struct tag",
+			src: `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+type Wrapper struct {
+	Key KeyType ` + "`db:\"key_type\" json:\"KeyType\"`" + `
+}
+`,
+			typeSet: map[string]string{"KeyType": "int"},
+			// the db tag was already hand-written as "key_type" (nothing
+			// to substitute there); the json tag's "KeyType" value does
+			// get rewritten, snake-casing the specific type, not the
+			// generic alias name - see structtag.go's Transform semantics
+			want:    []string{`Key int`, `db:"key_type"`, `json:"int"`},
+			notWant: []string{"generic.Type", "type KeyType"},
+		},
+		{
+			name: "embedded field",
+			src: `package example
+
+import "generic"
+
+type ValueType generic.Type
+
+type Holder struct {
+	ValueType
+}
+`,
+			typeSet: map[string]string{"ValueType": "string"},
+			// a literal full-identifier match returns the specific type
+			// verbatim (subIntoLiteral's short-circuit), so the embedded
+			// field becomes lowercase "string", not "String"
+			want:    []string{"string\n"},
+			notWant: []string{"ValueType", "generic.Type", "type ValueType"},
+		},
+		{
+			name: "type assertion",
+			src: `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+func check(v interface{}) bool {
+	_, ok := v.(KeyType)
+	return ok
+}
+`,
+			typeSet: map[string]string{"KeyType": "int"},
+			want:    []string{"v.(int)"},
+			notWant: []string{"KeyType", "generic.Type", "type KeyType"},
+		},
+		{
+			name: "composite literal",
+			src: `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+func zero() KeyType {
+	return KeyType{}
+}
+`,
+			typeSet: map[string]string{"KeyType": "int"},
+			want:    []string{"func zero() int", "return int{}"},
+			notWant: []string{"KeyType", "generic.Type", "type KeyType"},
+		},
+		{
+			name: "method receiver",
+			src: `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+func (k KeyType) String() string {
+	return ""
+}
+`,
+			typeSet: map[string]string{"KeyType": "int"},
+			want:    []string{"func (k int) String()"},
+			notWant: []string{"KeyType", "generic.Type", "type KeyType"},
+		},
+		{
+			name: "block comment keeps its line breaks",
+			src: `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+/*
+KeyType is the key.
+It must be comparable.
+*/
+func zero() KeyType {
+	return KeyType{}
+}
+`,
+			typeSet: map[string]string{"KeyType": "int"},
+			want:    []string{"/*\nint is the key.\nIt must be comparable.\n*/"},
+			notWant: []string{"KeyType", "generic.Type", "type KeyType"},
+		},
+		{
+			name: "unrelated identifier containing the template name is left alone",
+			src: `package example
+
+import "generic"
+
+type KeyType generic.Type
+
+func KeyTypeUnrelatedHelper() {}
+
+func use() KeyType {
+	return KeyTypeUnrelatedHelper, KeyType{}
+}
+`,
+			typeSet: map[string]string{"KeyType": "int"},
+			want:    []string{"KeyTypeUnrelatedHelper"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rewriteSource(t, tt.src, tt.typeSet)
+
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("output missing %q\ngot:\n%s", w, got)
+				}
+			}
+			for _, nw := range tt.notWant {
+				if strings.Contains(got, nw) {
+					t.Errorf("output should not contain %q\ngot:\n%s", nw, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package parse
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+// StructTagRewriter transforms the value of a single struct tag key
+// (e.g. the `db` in `db:"key_type"`) when the generic type it refers
+// to is substituted for a specific one.
+type StructTagRewriter interface {
+	// Match reports whether this rewriter handles tagKey (e.g. "json").
+	Match(tagKey string) bool
+
+	// Transform returns the tag value to use for the given generic
+	// type name and its specific substitution.
+	Transform(typeName, specificName string) string
+}
+
+// structTagRewriterFunc adapts a tag key and a transform function into
+// a StructTagRewriter.
+type structTagRewriterFunc struct {
+	key       string
+	transform func(typeName, specificName string) string
+}
+
+func (r structTagRewriterFunc) Match(tagKey string) bool {
+	return r.key == tagKey
+}
+
+func (r structTagRewriterFunc) Transform(typeName, specificName string) string {
+	return r.transform(typeName, specificName)
+}
+
+// structTagRewriters is the registry of known struct tag keys,
+// searched in order so a user-registered rewriter can override a
+// default by registering the same key again.
+var structTagRewriters []StructTagRewriter
+
+func init() {
+	RegisterStructTagRewriter("json", snakeCaseStructTag)
+	RegisterStructTagRewriter("db", snakeCaseStructTag)
+	RegisterStructTagRewriter("yaml", snakeCaseStructTag)
+	RegisterStructTagRewriter("xml", snakeCaseStructTag)
+	RegisterStructTagRewriter("toml", snakeCaseStructTag)
+	RegisterStructTagRewriter("bson", snakeCaseStructTag)
+	RegisterStructTagRewriter("mapstructure", snakeCaseStructTag)
+	RegisterStructTagRewriter("validate", snakeCaseStructTag)
+	RegisterStructTagRewriter("form", kebabCaseStructTag)
+	RegisterStructTagRewriter("protobuf", originalCaseStructTag)
+}
+
+func snakeCaseStructTag(typeName, specificName string) string {
+	capitalizedName := wordify(typeName, specificName, true)
+
+	snakeCaseName, ok := cacheSnakeCaseNames[capitalizedName]
+	if !ok {
+		snakeCaseName = strcase.ToSnake(capitalizedName)
+		cacheSnakeCaseNames[capitalizedName] = snakeCaseName
+	}
+
+	return snakeCaseName
+}
+
+func kebabCaseStructTag(typeName, specificName string) string {
+	return strings.Replace(snakeCaseStructTag(typeName, specificName), "_", "-", -1)
+}
+
+func originalCaseStructTag(typeName, specificName string) string {
+	return wordify(typeName, specificName, true)
+}
+
+// RegisterStructTagRewriter registers rewriter for a struct tag key,
+// e.g. RegisterStructTagRewriter("hcl", myRewriter). Registering the
+// same key again replaces the previous rewriter for that key.
+func RegisterStructTagRewriter(tagKey string, transform func(typeName, specificName string) string) {
+	for i, r := range structTagRewriters {
+		if r.Match(tagKey) {
+			structTagRewriters[i] = structTagRewriterFunc{key: tagKey, transform: transform}
+			return
+		}
+	}
+
+	structTagRewriters = append(structTagRewriters, structTagRewriterFunc{key: tagKey, transform: transform})
+}
+
+// structTagRewriterFor returns the registered rewriter for tagKey, if
+// any.
+func structTagRewriterFor(tagKey string) (StructTagRewriter, bool) {
+	for _, r := range structTagRewriters {
+		if r.Match(tagKey) {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// structTagPair locates a single key:"value" pair within a raw struct
+// tag (backticks already trimmed), recording the byte span of the
+// whole pair so subIntoStructTag can replace just the pairs it
+// touches and leave everything else - whitespace, ordering, keys with
+// no registered rewriter - untouched.
+type structTagPair struct {
+	key        string
+	start, end int
+}
+
+// structTagPairs enumerates every key:"value" pair in tag by walking
+// the same grammar reflect.StructTag.Lookup parses one key at a time:
+// reflect.StructTag has no exported way to list its keys, only
+// Lookup(key) for a single key, so finding each key's span (needed to
+// rewrite only the pairs that change, in place) means re-walking that
+// grammar by hand. The value for each key found is still read back
+// via tag.Lookup rather than re-derived here, so unescaping and
+// well-formedness stay authoritative.
+func structTagPairs(tag string) []structTagPair {
+	var pairs []structTagPair
+	rest := tag
+	offset := 0
+
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] == ' ' {
+			i++
+		}
+		rest = rest[i:]
+		offset += i
+		if rest == "" {
+			break
+		}
+		start := offset
+
+		i = 0
+		for i < len(rest) && rest[i] > ' ' && rest[i] != ':' && rest[i] != '"' && rest[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(rest) || rest[i] != ':' || rest[i+1] != '"' {
+			break
+		}
+		key := rest[:i]
+		rest = rest[i+1:]
+		offset += i + 1
+
+		j := 1
+		for j < len(rest) && rest[j] != '"' {
+			if rest[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(rest) {
+			break
+		}
+
+		pairs = append(pairs, structTagPair{key: key, start: start, end: offset + j + 1})
+		rest = rest[j+1:]
+		offset += j + 1
+	}
+
+	return pairs
+}
+
+// subIntoStructTag rewrites every key in the raw struct tag literal
+// lit (backtick-quoted, e.g. `` `json:"KeyType" db:"KeyType"` ``) whose
+// value contains typeTemplate, dispatching each key to its registered
+// StructTagRewriter. Keys with no registered rewriter, and keys whose
+// value doesn't contain typeTemplate, are left untouched.
+func subIntoStructTag(lit, typeTemplate, specificType, specificName string) string {
+	raw := strings.Trim(lit, "`")
+	tag := reflect.StructTag(raw)
+
+	var out strings.Builder
+	prevEnd := 0
+	for _, pair := range structTagPairs(raw) {
+		value, ok := tag.Lookup(pair.key)
+		if !ok || !strings.Contains(value, typeTemplate) {
+			continue
+		}
+
+		rewriter, ok := structTagRewriterFor(pair.key)
+		if !ok {
+			continue
+		}
+
+		value = strings.Replace(value, typeTemplate, rewriter.Transform(specificType, specificName), -1)
+
+		out.WriteString(raw[prevEnd:pair.start])
+		out.WriteString(pair.key)
+		out.WriteString(":")
+		out.WriteString(strconv.Quote(value))
+		prevEnd = pair.end
+	}
+	out.WriteString(raw[prevEnd:])
+
+	return "`" + out.String() + "`"
+}
+
+// isStructTag reports whether lit is a backtick-quoted struct tag,
+// i.e. it parses as at least one `key:"value"` pair.
+func isStructTag(lit string) bool {
+	if len(lit) < 2 || lit[0] != '`' || lit[len(lit)-1] != '`' {
+		return false
+	}
+
+	return len(structTagPairs(strings.Trim(lit, "`"))) > 0
+}
@@ -0,0 +1,69 @@
+package parse
+
+import "testing"
+
+func TestSubIntoStructTag(t *testing.T) {
+	tests := []struct {
+		name string
+		lit  string
+		want string
+	}{
+		{
+			// Transform receives the specific type ("int") and snake-cases
+			// that, not the generic alias name ("KeyType") being replaced
+			name: "single json key",
+			lit:  "`json:\"KeyType\"`",
+			want: "`json:\"int\"`",
+		},
+		{
+			name: "multiple keys each rewritten independently",
+			lit:  "`json:\"KeyType\" db:\"KeyType\" validate:\"required\"`",
+			want: "`json:\"int\" db:\"int\" validate:\"required\"`",
+		},
+		{
+			name: "form uses kebab-case",
+			lit:  "`form:\"KeyType\"`",
+			want: "`form:\"int\"`",
+		},
+		{
+			// originalCaseStructTag runs the specific type through wordify's
+			// capitalized form, same as any other identifier substitution
+			name: "protobuf keeps original casing",
+			lit:  "`protobuf:\"bytes,1,opt,name=KeyType\"`",
+			want: "`protobuf:\"bytes,1,opt,name=Int\"`",
+		},
+		{
+			name: "unregistered tag key is left alone",
+			lit:  "`custom:\"KeyType\"`",
+			want: "`custom:\"KeyType\"`",
+		},
+		{
+			// the value's escaped quote must survive unescaping (via
+			// reflect.StructTag.Lookup) and requoting unchanged
+			name: "value with an escaped quote",
+			lit:  "`validate:\"required,oneof=KeyType \\\"other\\\"\"`",
+			want: "`validate:\"required,oneof=int \\\"other\\\"\"`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subIntoStructTag(tt.lit, "KeyType", "int", "")
+			if got != tt.want {
+				t.Errorf("subIntoStructTag(%q) = %q, want %q", tt.lit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterStructTagRewriter(t *testing.T) {
+	RegisterStructTagRewriter("hcl", func(typeName, specificName string) string {
+		return "custom_" + wordify(typeName, specificName, false)
+	})
+
+	got := subIntoStructTag("`hcl:\"KeyType\"`", "KeyType", "int", "")
+	want := "`hcl:\"custom_int\"`"
+	if got != want {
+		t.Errorf("subIntoStructTag with registered rewriter = %q, want %q", got, want)
+	}
+}
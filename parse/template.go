@@ -0,0 +1,155 @@
+package parse
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+	"golang.org/x/tools/imports"
+)
+
+// Template is a second front-end for the parse package: instead of
+// `generic.Type` aliases, it consumes Go source written with
+// text/template actions (e.g. `{{.KeyType}}`, `{{.KeyType | lower}}`,
+// `{{if eq .KeyType "string"}}...{{end}}`, `{{range $k, $v := .}}...
+// {{end}}`), giving conditionals, loops over multiple type
+// parameters, and derived identifier forms that would otherwise
+// require writing Go that merely happens to parse.
+//
+// filename is used only for error messages and as the template's
+// name. For each typeSet in typeSets (the same "KeyType": "int" shape
+// Generics takes), the template is executed with typeSet as its root
+// data and the results concatenated, mirroring how Generics produces
+// one copy per typeSet. funcs is merged over (and may override) the
+// default FuncMap: lower, upper, title, snake, camel, pascal, kebab,
+// plural, singular, gotype and pkg.
+//
+// Template-mode files are conventionally named with a .gotpl
+// extension, or carry a leading `// +build genny:template` line, so
+// callers can tell them apart from generic.Type sources and dispatch
+// to Template instead of Generics; Template itself doesn't care how
+// it was invoked.
+func Template(filename string, in io.Reader, typeSets []map[string]string, funcs template.FuncMap) ([]byte, error) {
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// the same importSet machinery Generics uses: every import the
+	// source already declares, plus whatever package a specific type
+	// pulls in, merged into a single import block below instead of
+	// leaning on imports.Process to rediscover everything from scratch
+	importSetForOutput := newImportSet(nil)
+	recordImportsFor(importSetForOutput, filename, bytes.NewReader(src), typeSets)
+
+	totalOutput := append([]byte{}, header...)
+
+	for _, typeSet := range typeSets {
+		tmpl, err := template.New(filename).Funcs(templateFuncs(typeSet, funcs)).Parse(string(src))
+		if err != nil {
+			return nil, &errSource{Err: err}
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, typeSet); err != nil {
+			return nil, err
+		}
+
+		totalOutput = append(totalOutput, buf.Bytes()...)
+	}
+
+	return cleanTemplateOutput(filename, totalOutput, importSetForOutput)
+}
+
+// templateFuncs builds the FuncMap a typeSet's template execution runs
+// with: the package defaults, a gotype closure bound to this
+// typeSet, then whatever the caller supplied in funcs (which may
+// override any of the above).
+func templateFuncs(typeSet map[string]string, funcs template.FuncMap) template.FuncMap {
+	fm := template.FuncMap{
+		"lower":    strings.ToLower,
+		"upper":    strings.ToUpper,
+		"title":    strings.Title,
+		"snake":    strcase.ToSnake,
+		"camel":    strcase.ToLowerCamel,
+		"pascal":   strcase.ToCamel,
+		"kebab":    toKebab,
+		"plural":   pluralize,
+		"singular": singularize,
+		"gotype": func(name string) string {
+			return typeSet[name]
+		},
+		"pkg": func(specificType string) string {
+			shorthand, ok := packageShorthand(specificType)
+			if !ok {
+				return ""
+			}
+			return shorthand
+		},
+	}
+
+	for name, fn := range funcs {
+		fm[name] = fn
+	}
+
+	return fm
+}
+
+// toKebab converts s to kebab-case, e.g. "KeyType" -> "key-type".
+func toKebab(s string) string {
+	return strings.Replace(strcase.ToSnake(s), "_", "-", -1)
+}
+
+// pluralize applies naive English pluralization rules - good enough
+// for deriving things like table or variable names, not a substitute
+// for a real inflection dictionary.
+func pluralize(s string) string {
+	if s == "" {
+		return s
+	}
+
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "y") && len(s) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return s[:len(s)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return s + "es"
+	default:
+		return s + "s"
+	}
+}
+
+// singularize reverses the common cases pluralize handles.
+func singularize(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(s) > 3:
+		return s[:len(s)-3] + "y"
+	case strings.HasSuffix(lower, "ses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "zes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return s[:len(s)-2]
+	case strings.HasSuffix(lower, "s"):
+		return s[:len(s)-1]
+	default:
+		return s
+	}
+}
+
+// cleanTemplateOutput collapses the concatenated per-typeSet
+// executions down to a single package clause and the merged import
+// block in set - mergeImports, the same helper Generics calls, so the
+// two front-ends don't diverge on import handling - then runs
+// imports.Process as a best-effort formatter.
+func cleanTemplateOutput(filename string, totalOutput []byte, set *importSet) ([]byte, error) {
+	output := mergeImports(totalOutput, set)
+
+	if formatted, err := imports.Process(filename, output, nil); err == nil {
+		output = formatted
+	}
+
+	return output, nil
+}
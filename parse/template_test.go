@@ -0,0 +1,91 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	src := `package example
+
+type {{.KeyType | pascal}}List []{{.KeyType}}
+
+func New{{.KeyType | pascal}}List() {{.KeyType | pascal}}List {
+	return {{.KeyType | pascal}}List{}
+}
+`
+
+	out, err := Template("list.gotpl", strings.NewReader(src), []map[string]string{
+		{"KeyType": "int"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Template: %v", err)
+	}
+
+	got := string(out)
+	for _, want := range []string{"type IntList []int", "func NewIntList() IntList", "return IntList{}"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestTemplateMultipleTypeSets(t *testing.T) {
+	src := `package example
+
+type {{.KeyType | pascal}}Set map[{{.KeyType}}]struct{}
+`
+
+	out, err := Template("set.gotpl", strings.NewReader(src), []map[string]string{
+		{"KeyType": "int"},
+		{"KeyType": "string"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Template: %v", err)
+	}
+
+	got := string(out)
+	for _, want := range []string{"type IntSet map[int]struct{}", "type StringSet map[string]struct{}"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestTemplateCustomFuncOverride(t *testing.T) {
+	src := `package example
+
+// {{shout "hello"}}
+`
+
+	out, err := Template("custom.gotpl", strings.NewReader(src), []map[string]string{{}}, map[string]interface{}{
+		"shout": func(s string) string { return strings.ToUpper(s) + "!" },
+	})
+	if err != nil {
+		t.Fatalf("Template: %v", err)
+	}
+
+	if !strings.Contains(string(out), "HELLO!") {
+		t.Errorf("expected custom func to run, got:\n%s", out)
+	}
+}
+
+func TestPluralizeSingularize(t *testing.T) {
+	tests := []struct {
+		singular, plural string
+	}{
+		{"Key", "Keys"},
+		{"Class", "Classes"},
+		{"Entity", "Entities"},
+		{"Box", "Boxes"},
+	}
+
+	for _, tt := range tests {
+		if got := pluralize(tt.singular); got != tt.plural {
+			t.Errorf("pluralize(%q) = %q, want %q", tt.singular, got, tt.plural)
+		}
+		if got := singularize(tt.plural); got != tt.singular {
+			t.Errorf("singularize(%q) = %q, want %q", tt.plural, got, tt.singular)
+		}
+	}
+}